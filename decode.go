@@ -0,0 +1,389 @@
+package env
+
+import (
+	"bufio"
+	"bytes"
+	"encoding"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Unmarshaler is implemented by types that can populate themselves from a
+// shell variable value. It takes precedence over encoding.TextUnmarshaler
+// and over the built-in reflect-kind handling, mirroring how Marshaler beats
+// encoding.TextMarshaler on the encoding side.
+type Unmarshaler interface {
+	UnmarshalEnvValue(value string) error
+}
+
+// Unmarshal parses shell-style environment assignments from data and stores
+// the result in the struct pointed to by v. It accepts both `export
+// NAME='value'` lines and plain dotenv-style `NAME=value` lines, using the
+// same field tagging (`env:"NAME"`), nested `TAG_FieldName` naming rules,
+// `env:"-"` skipping, `prefix=`, the `bool=truthy/falsy` format and
+// embedded-struct dominant-field resolution as Marshal. String, int/uint,
+// float, bool and struct fields are populated; slices, arrays and maps are
+// not yet decoded. A variable present for one of those unsupported types
+// returns an UnsupportedTypeError; an absent variable leaves the field at
+// its zero value.
+func Unmarshal(data []byte, v interface{}) error {
+	return NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// UnmarshalEnviron parses "NAME=value" pairs in the form returned by
+// os.Environ() and stores the result in the struct pointed to by v. Unlike
+// Unmarshal, entries are already split and unquoted by the OS, so a value
+// containing a literal newline (e.g. a multi-line PEM variable) is handled
+// correctly instead of being mistaken for a second assignment.
+func UnmarshalEnviron(environ []string, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("env: UnmarshalEnviron requires a non-nil pointer, got %T", v)
+	}
+
+	vals := make(map[string]string, len(environ))
+	for _, entry := range environ {
+		idx := strings.Index(entry, "=")
+		if idx < 0 {
+			return fmt.Errorf("env: invalid environ entry %q: missing '='", entry)
+		}
+		vals[entry[:idx]] = entry[idx+1:]
+	}
+
+	return visitUnmarshal(rv.Elem(), "", tagOptions(""), vals, 0)
+}
+
+// Decoder reads shell-style environment assignments from an io.Reader and
+// decodes them into structs tagged with `env:"NAME"`.
+type Decoder struct {
+	s *bufio.Scanner
+}
+
+// NewDecoder returns a new Decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{s: bufio.NewScanner(r)}
+}
+
+// Decode reads all assignments from the underlying reader and stores the
+// result in the struct pointed to by v.
+func (d *Decoder) Decode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("env: Decode requires a non-nil pointer, got %T", v)
+	}
+
+	vals := make(map[string]string)
+	for lineNo := 1; d.s.Scan(); lineNo++ {
+		raw := d.s.Text()
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		colOffset := leadingWidth(raw)
+
+		stripped := strings.TrimPrefix(line, "export ")
+		if stripped != line {
+			colOffset += len("export ")
+		}
+		line = strings.TrimSpace(stripped)
+		colOffset += leadingWidth(stripped)
+
+		name, value, err := parseAssignment(line, lineNo, colOffset)
+		if err != nil {
+			return err
+		}
+		vals[name] = value
+	}
+	if err := d.s.Err(); err != nil {
+		return err
+	}
+
+	return visitUnmarshal(rv.Elem(), "", tagOptions(""), vals, 0)
+}
+
+// maxUnmarshalDepth bounds how many pointer indirections visitUnmarshal will
+// follow while allocating nil pointers. It exists solely to turn a
+// self-referential pointer struct (e.g. a linked-list node whose Next field
+// reuses its own env tag at every level) into an error instead of a stack
+// overflow; ordinary struct nesting never comes close to it.
+const maxUnmarshalDepth = 32
+
+// leadingWidth returns the number of leading bytes TrimSpace would strip
+// from s, so callers can turn a column within the trimmed string back into
+// a column within the original.
+func leadingWidth(s string) int {
+	return len(s) - len(strings.TrimLeftFunc(s, unicode.IsSpace))
+}
+
+// parseAssignment splits a single "NAME=value" (or "NAME='value'" etc.) line
+// into its name and decoded value. colOffset is the number of bytes already
+// trimmed off the front of line (leading whitespace, the "export " prefix,
+// and any whitespace after it), so that reported SyntaxError columns point
+// at the original, untrimmed source line rather than line itself.
+func parseAssignment(line string, lineNo, colOffset int) (name, value string, err error) {
+	idx := strings.Index(line, "=")
+	if idx < 0 {
+		return "", "", &SyntaxError{Line: lineNo, Column: colOffset + len(line) + 1, Msg: "expected '=' after variable name"}
+	}
+	name = strings.TrimSpace(line[:idx])
+	if name == "" {
+		return "", "", &SyntaxError{Line: lineNo, Column: colOffset + 1, Msg: "missing variable name"}
+	}
+
+	value, err = unquote(line[idx+1:], lineNo, colOffset+idx+2)
+	if err != nil {
+		return "", "", err
+	}
+	return name, value, nil
+}
+
+// unquote decodes the value portion of an assignment, honoring
+// single-quoted, double-quoted and unquoted (with backslash escapes)
+// segments, concatenated shell-style (e.g. 'foo'"bar"baz).
+func unquote(s string, lineNo, startCol int) (string, error) {
+	var out strings.Builder
+	col := startCol
+	i := 0
+	for i < len(s) {
+		switch c := s[i]; {
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return "", &SyntaxError{Line: lineNo, Column: col, Msg: "unterminated single-quoted string"}
+			}
+			out.WriteString(s[i+1 : i+1+end])
+			col += end + 2
+			i += end + 2
+		case c == '"':
+			n, consumed, err := unquoteDouble(s[i+1:], lineNo, col+1)
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(n)
+			col += consumed + 2
+			i += consumed + 2
+		case c == '\\':
+			if i+1 >= len(s) {
+				return "", &SyntaxError{Line: lineNo, Column: col, Msg: "trailing backslash"}
+			}
+			out.WriteByte(s[i+1])
+			col += 2
+			i += 2
+		default:
+			out.WriteByte(c)
+			col++
+			i++
+		}
+	}
+	return out.String(), nil
+}
+
+// unquoteDouble decodes the contents of a double-quoted string starting
+// right after the opening quote. It returns the decoded value and the
+// number of input bytes consumed, not counting the closing quote.
+func unquoteDouble(s string, lineNo, startCol int) (string, int, error) {
+	var out strings.Builder
+	i := 0
+	for i < len(s) {
+		switch c := s[i]; {
+		case c == '"':
+			return out.String(), i, nil
+		case c == '\\' && i+1 < len(s):
+			switch next := s[i+1]; next {
+			case '"', '\\', '$', '`':
+				out.WriteByte(next)
+			case 'n':
+				out.WriteByte('\n')
+			case 't':
+				out.WriteByte('\t')
+			case 'r':
+				out.WriteByte('\r')
+			default:
+				out.WriteByte('\\')
+				out.WriteByte(next)
+			}
+			i += 2
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return "", 0, &SyntaxError{Line: lineNo, Column: startCol, Msg: "unterminated double-quoted string"}
+}
+
+// SyntaxError reports a malformed assignment found while decoding.
+type SyntaxError struct {
+	Line   int
+	Column int
+	Msg    string
+}
+
+// Error returns a string representation of the syntax error.
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("env: syntax error at line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+// visitUnmarshal mirrors visitValue's recursive walk, but in the decoding
+// direction: it fills v from vals instead of writing v into a buffer. depth
+// counts pointer indirections allocated so far, guarding against
+// self-referential pointer struct types (see maxUnmarshalDepth).
+func visitUnmarshal(v reflect.Value, tag string, opts tagOptions, vals map[string]string, depth int) error {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		if !hasRelevantValue(tag, vals) {
+			return nil
+		}
+		if depth >= maxUnmarshalDepth {
+			return fmt.Errorf("env: exceeded maximum nesting depth (%d) unmarshalling %q; check for a recursive struct type", maxUnmarshalDepth, tag)
+		}
+		v.Set(reflect.New(v.Type().Elem()))
+	}
+
+	if tag != "" {
+		if raw, ok := vals[tag]; ok {
+			if handled, err := unmarshalCustom(v, raw); handled || err != nil {
+				return err
+			}
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		return visitUnmarshal(v.Elem(), tag, opts, vals, depth+1)
+	case reflect.Struct:
+		fields, err := resolveStructFields(v, tag)
+		if err != nil {
+			return err
+		}
+		for _, f := range fields {
+			if err := visitUnmarshal(f.value, f.name, f.opts, vals, depth); err != nil {
+				return fmt.Errorf("unmarshalling %v: %w", f.label, err)
+			}
+		}
+		return nil
+	default:
+		if tag == "" {
+			return nil
+		}
+		raw, ok := vals[tag]
+		if !ok {
+			return nil
+		}
+		return setScalar(v, raw, opts)
+	}
+}
+
+// hasRelevantValue reports whether vals has an entry that could populate tag
+// or one of its descendants, using the same TAG_FieldName / prefix= joining
+// rule as joinName. visitUnmarshal consults it before allocating a nil
+// pointer, so a *T field with nothing to populate is left nil instead of
+// ending up non-nil with zero-valued contents.
+func hasRelevantValue(tag string, vals map[string]string) bool {
+	if tag == "" {
+		return false
+	}
+	if _, ok := vals[tag]; ok {
+		return true
+	}
+	prefix := tag
+	if !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+	for k := range vals {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// unmarshalCustom checks whether v (or, if addressable, a pointer to v)
+// implements Unmarshaler or encoding.TextUnmarshaler, in that order of
+// precedence, and if so assigns raw through it, reporting handled=true.
+func unmarshalCustom(v reflect.Value, raw string) (handled bool, err error) {
+	if !v.CanInterface() {
+		return false, nil
+	}
+
+	if m, ok := v.Interface().(Unmarshaler); ok {
+		return true, m.UnmarshalEnvValue(raw)
+	}
+	if m, ok := v.Interface().(encoding.TextUnmarshaler); ok {
+		return true, m.UnmarshalText([]byte(raw))
+	}
+
+	if v.CanAddr() {
+		addr := v.Addr()
+		if m, ok := addr.Interface().(Unmarshaler); ok {
+			return true, m.UnmarshalEnvValue(raw)
+		}
+		if m, ok := addr.Interface().(encoding.TextUnmarshaler); ok {
+			return true, m.UnmarshalText([]byte(raw))
+		}
+	}
+
+	return false, nil
+}
+
+// setScalar converts raw into v's underlying kind and assigns it. opts is
+// the field's tag options, consulted for a `bool=truthy/falsy` format.
+func setScalar(v reflect.Value, raw string, opts tagOptions) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("env: invalid int value %q: %w", raw, err)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(raw, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("env: invalid uint value %q: %w", raw, err)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("env: invalid float value %q: %w", raw, err)
+		}
+		v.SetFloat(n)
+	case reflect.Bool:
+		b, err := parseBool(raw, opts)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	default:
+		return UnsupportedTypeError(v.Type().String())
+	}
+	return nil
+}
+
+// parseBool parses raw as a bool, honoring the tag's `bool=truthy/falsy`
+// option (the decoding counterpart of formatBool) and falling back to
+// strconv.ParseBool otherwise.
+func parseBool(raw string, opts tagOptions) (bool, error) {
+	if spec, ok := opts.Value("bool"); ok {
+		if idx := strings.Index(spec, "/"); idx != -1 {
+			truthy, falsy := spec[:idx], spec[idx+1:]
+			switch raw {
+			case truthy:
+				return true, nil
+			case falsy:
+				return false, nil
+			default:
+				return false, fmt.Errorf("env: invalid bool value %q: want %q or %q", raw, truthy, falsy)
+			}
+		}
+	}
+	b, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("env: invalid bool value %q: %w", raw, err)
+	}
+	return b, nil
+}
@@ -0,0 +1,319 @@
+package env_test
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jgf/env"
+)
+
+func TestUnmarshalSimpleStruct(t *testing.T) {
+	var simple struct {
+		A       string `env:"MYVAR"`
+		B       int    `env:"B"`
+		Ignored int
+	}
+
+	input := "export MYVAR='hallo'\nexport B='4711'\n"
+	if err := env.Unmarshal([]byte(input), &simple); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if simple.A != "hallo" || simple.B != 4711 {
+		t.Errorf("unmarshalled struct does not match expectation: %+v", simple)
+	}
+	if simple.Ignored != 0 {
+		t.Errorf("untagged field should remain untouched, got %v", simple.Ignored)
+	}
+}
+
+func TestUnmarshalDotenvStyle(t *testing.T) {
+	var simple struct {
+		A string `env:"MYVAR"`
+		B int    `env:"B"`
+	}
+
+	input := "MYVAR=hallo\nB=4711\n"
+	if err := env.Unmarshal([]byte(input), &simple); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if simple.A != "hallo" || simple.B != 4711 {
+		t.Errorf("unmarshalled struct does not match expectation: %+v", simple)
+	}
+}
+
+func TestUnmarshalMultiLayeredStruct(t *testing.T) {
+	var simple struct {
+		S1 sA  `env:"S1"`
+		S2 ssB `env:"S2"`
+	}
+
+	input := "export MYVAR='hallo'\nexport S1_C='welt'\nexport B='4711'\n"
+	if err := env.Unmarshal([]byte(input), &simple); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if simple.S1.A != "hallo" || simple.S1.C != "welt" || simple.S2.S.B != 4711 {
+		t.Errorf("unmarshalled struct does not match expectation: %+v", simple)
+	}
+}
+
+func TestUnmarshalQuotingForms(t *testing.T) {
+	var simple struct {
+		A string `env:"A"`
+		B string `env:"B"`
+		C string `env:"C"`
+		D string `env:"D"`
+	}
+
+	input := "A='single quoted'\nB=\"double \\\"quoted\\\"\"\nC=unquoted\nD=escaped\\ space\n"
+	if err := env.Unmarshal([]byte(input), &simple); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if simple.A != "single quoted" {
+		t.Errorf("A: got %q", simple.A)
+	}
+	if simple.B != `double "quoted"` {
+		t.Errorf("B: got %q", simple.B)
+	}
+	if simple.C != "unquoted" {
+		t.Errorf("C: got %q", simple.C)
+	}
+	if simple.D != "escaped space" {
+		t.Errorf("D: got %q", simple.D)
+	}
+}
+
+func TestUnmarshalEnviron(t *testing.T) {
+	var simple struct {
+		A string `env:"MYVAR"`
+		B int    `env:"B"`
+	}
+
+	if err := env.UnmarshalEnviron([]string{"MYVAR=hallo", "B=4711"}, &simple); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if simple.A != "hallo" || simple.B != 4711 {
+		t.Errorf("unmarshalled struct does not match expectation: %+v", simple)
+	}
+}
+
+func TestUnmarshalEnvironValueWithNewline(t *testing.T) {
+	var simple struct {
+		A string `env:"A"`
+		B string `env:"B"`
+	}
+
+	if err := env.UnmarshalEnviron([]string{"A=line1\nline2", "B=ok"}, &simple); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if simple.A != "line1\nline2" || simple.B != "ok" {
+		t.Errorf("unmarshalled struct does not match expectation: %+v", simple)
+	}
+}
+
+func TestUnmarshalSkipTag(t *testing.T) {
+	var simple struct {
+		A string `env:"MYVAR"`
+		B string `env:"-"`
+	}
+
+	if err := env.Unmarshal([]byte("MYVAR=hallo\n-=welt\n"), &simple); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if simple.A != "hallo" || simple.B != "" {
+		t.Errorf("unmarshalled struct does not match expectation: %+v", simple)
+	}
+}
+
+func TestUnmarshalStructFieldPrefixOption(t *testing.T) {
+	var simple struct {
+		S1 sA `env:",prefix=PRE_"`
+	}
+
+	input := "export MYVAR=hallo\nexport PRE_C=welt\n"
+	if err := env.Unmarshal([]byte(input), &simple); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if simple.S1.A != "hallo" || simple.S1.C != "welt" {
+		t.Errorf("unmarshalled struct does not match expectation: %+v", simple)
+	}
+}
+
+func TestUnmarshalEmbeddedStructAmbiguousCollisionError(t *testing.T) {
+	var simple struct {
+		ambiguousA
+		ambiguousB
+	}
+
+	err := env.Unmarshal([]byte("HOST=hallo\n"), &simple)
+	if err == nil {
+		t.Errorf("expected error did not occur")
+	}
+}
+
+type ms int
+
+func (d *ms) UnmarshalEnvValue(value string) error {
+	n, err := strconv.Atoi(strings.TrimSuffix(value, "ms"))
+	if err != nil {
+		return err
+	}
+	*d = ms(n)
+	return nil
+}
+
+func TestUnmarshalerTakesPrecedence(t *testing.T) {
+	var simple struct {
+		D ms `env:"D"`
+	}
+
+	if err := env.Unmarshal([]byte("D=1500ms\n"), &simple); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if simple.D != 1500 {
+		t.Errorf("D: got %v", simple.D)
+	}
+}
+
+func (h *hexColor) UnmarshalText(text []byte) error {
+	n, err := strconv.ParseUint(strings.TrimPrefix(string(text), "#"), 16, 32)
+	if err != nil {
+		return err
+	}
+	*h = hexColor(n)
+	return nil
+}
+
+func TestTextUnmarshalerFallback(t *testing.T) {
+	var simple struct {
+		C hexColor `env:"COLOR"`
+	}
+
+	if err := env.Unmarshal([]byte("COLOR=#ff00aa\n"), &simple); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if simple.C != 0xff00aa {
+		t.Errorf("C: got %#x", uint32(simple.C))
+	}
+}
+
+func TestUnmarshalBoolDefaultFormat(t *testing.T) {
+	var simple struct {
+		A bool `env:"FLAG"`
+	}
+
+	if err := env.Unmarshal([]byte("FLAG=true\n"), &simple); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !simple.A {
+		t.Errorf("A: got %v, want true", simple.A)
+	}
+}
+
+func TestUnmarshalBoolCustomFormat(t *testing.T) {
+	var simple struct {
+		A bool `env:"FLAG,bool=1/0"`
+	}
+
+	if err := env.Unmarshal([]byte("FLAG=1\n"), &simple); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !simple.A {
+		t.Errorf("A: got %v, want true", simple.A)
+	}
+}
+
+func TestUnmarshalErrorUnsupportedType(t *testing.T) {
+	var simple struct {
+		A chan int `env:"MYCHAN"`
+	}
+
+	err := env.Unmarshal([]byte("MYCHAN=42\n"), &simple)
+	if err == nil {
+		t.Errorf("expected error did not occur")
+	} else if !errors.Is(err, env.UnsupportedTypeError("chan int")) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestUnmarshalErrorSyntax(t *testing.T) {
+	err := env.Unmarshal([]byte("NOEQUALSSIGN\n"), &struct{}{})
+	var syntaxErr *env.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Errorf("expected a *env.SyntaxError, got: %v", err)
+	}
+}
+
+func TestUnmarshalErrorUnterminatedQuote(t *testing.T) {
+	var simple struct {
+		A string `env:"A"`
+	}
+
+	err := env.Unmarshal([]byte("A='unterminated\n"), &simple)
+	var syntaxErr *env.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Errorf("expected a *env.SyntaxError, got: %v", err)
+	}
+}
+
+func TestUnmarshalErrorColumnAccountsForExportAndIndentation(t *testing.T) {
+	var simple struct {
+		A string `env:"A"`
+	}
+
+	err := env.Unmarshal([]byte("export A='unterminated\n"), &simple)
+	var syntaxErr *env.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("expected a *env.SyntaxError, got: %v", err)
+	}
+	if syntaxErr.Column != 10 {
+		t.Errorf("Column: got %d, want 10", syntaxErr.Column)
+	}
+
+	err = env.Unmarshal([]byte("   export A='unterminated\n"), &simple)
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("expected a *env.SyntaxError, got: %v", err)
+	}
+	if syntaxErr.Column != 13 {
+		t.Errorf("Column: got %d, want 13", syntaxErr.Column)
+	}
+}
+
+type sInner struct {
+	A string `env:"A"`
+}
+
+func TestUnmarshalNilPointerLeftNilWithoutData(t *testing.T) {
+	var simple struct {
+		I *sInner `env:"I"`
+	}
+
+	if err := env.Unmarshal([]byte("UNRELATED=hallo\n"), &simple); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if simple.I != nil {
+		t.Errorf("I: got %+v, want nil", simple.I)
+	}
+}
+
+type node struct {
+	Next *node `env:"NEXT"`
+}
+
+func TestUnmarshalRecursivePointerStructErrorsInsteadOfOverflowing(t *testing.T) {
+	var n node
+
+	err := env.Unmarshal([]byte("NEXT=anything\n"), &n)
+	if err == nil {
+		t.Errorf("expected an error, got nil")
+	}
+}
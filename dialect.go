@@ -0,0 +1,113 @@
+package env
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Dialect controls how a single NAME=value assignment is rendered. Marshal
+// uses DialectPOSIX; MarshalWith and Encoder.SetDialect accept any Dialect.
+type Dialect interface {
+	// FormatAssignment renders one assignment, including its trailing
+	// newline.
+	FormatAssignment(name, value string) string
+}
+
+// arrayDialect is implemented by dialects that render slices/arrays with
+// their shell's native array syntax instead of a separator-joined scalar.
+// Dialects that don't implement it fall back to FormatAssignment with the
+// values joined by the field's `sep` tag option.
+type arrayDialect interface {
+	FormatArray(name string, values []string) string
+}
+
+// DialectPOSIX renders `export NAME=value` lines, the original behavior of
+// Marshal. Values are single-quoted whenever they are empty or contain a
+// shell-unsafe character (see shellquote), with embedded single quotes
+// escaped POSIX-style (' becomes '\''); otherwise they are emitted bare.
+type DialectPOSIX struct{}
+
+// FormatAssignment implements Dialect.
+func (DialectPOSIX) FormatAssignment(name, value string) string {
+	return fmt.Sprintf("export %s=%s\n", name, shellquote(value))
+}
+
+func posixQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// DialectBash is DialectPOSIX plus native bash array syntax for slices and
+// arrays: `export NAME=(a b c)`.
+type DialectBash struct {
+	DialectPOSIX
+}
+
+// FormatArray implements arrayDialect.
+func (DialectBash) FormatArray(name string, values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = shellquote(v)
+	}
+	return fmt.Sprintf("export %s=(%s)\n", name, strings.Join(quoted, " "))
+}
+
+// DialectFish renders `set -x NAME value` lines for the fish shell, quoting
+// as shellquote does for DialectPOSIX.
+type DialectFish struct{}
+
+// FormatAssignment implements Dialect.
+func (DialectFish) FormatAssignment(name, value string) string {
+	return fmt.Sprintf("set -x %s %s\n", name, shellquote(value))
+}
+
+// DialectPowerShell renders `$env:NAME = 'value'` lines for PowerShell.
+// Embedded single quotes are escaped by doubling them, PowerShell's
+// single-quoted string convention.
+type DialectPowerShell struct{}
+
+// FormatAssignment implements Dialect.
+func (DialectPowerShell) FormatAssignment(name, value string) string {
+	return fmt.Sprintf("$env:%s = %s\n", name, powershellQuote(value))
+}
+
+func powershellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// DialectDotenv renders `NAME="value"` lines suitable for a .env file, as
+// consumed by godotenv or Docker's `--env-file`. Backslashes, double quotes
+// and newlines are backslash-escaped.
+type DialectDotenv struct{}
+
+// FormatAssignment implements Dialect.
+func (DialectDotenv) FormatAssignment(name, value string) string {
+	return fmt.Sprintf("%s=%s\n", name, dotenvQuote(value))
+}
+
+func dotenvQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+// DialectSystemd renders `NAME=value` lines compatible with systemd's
+// EnvironmentFile: no `export` keyword, and no quoting of numeric values.
+type DialectSystemd struct{}
+
+// FormatAssignment implements Dialect.
+func (DialectSystemd) FormatAssignment(name, value string) string {
+	if isNumeric(value) {
+		return fmt.Sprintf("%s=%s\n", name, value)
+	}
+	return fmt.Sprintf("%s=%s\n", name, dotenvQuote(value))
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
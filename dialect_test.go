@@ -0,0 +1,93 @@
+package env_test
+
+import (
+	"testing"
+
+	"github.com/jgf/env"
+)
+
+func marshalWith(t *testing.T, d env.Dialect, v interface{}) string {
+	t.Helper()
+	data, err := env.MarshalWith(v, d)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return string(data)
+}
+
+func TestDialectPOSIX(t *testing.T) {
+	simple := struct {
+		A string `env:"A"`
+	}{A: `it's a $value "quoted" and
+multiline`}
+
+	got := marshalWith(t, env.DialectPOSIX{}, simple)
+	want := `export A='it'\''s a $value "quoted" and
+multiline'
+`
+	if got != want {
+		t.Errorf("got:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestDialectBashArray(t *testing.T) {
+	simple := struct {
+		A []string `env:"A"`
+	}{A: []string{"hallo welt", "it's"}}
+
+	got := marshalWith(t, env.DialectBash{}, simple)
+	want := "export A=('hallo welt' 'it'\\''s')\n"
+	if got != want {
+		t.Errorf("got:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestDialectFish(t *testing.T) {
+	simple := struct {
+		A string `env:"A"`
+	}{A: "hallo welt"}
+
+	got := marshalWith(t, env.DialectFish{}, simple)
+	want := "set -x A 'hallo welt'\n"
+	if got != want {
+		t.Errorf("got:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestDialectPowerShell(t *testing.T) {
+	simple := struct {
+		A string `env:"A"`
+	}{A: "it's a value"}
+
+	got := marshalWith(t, env.DialectPowerShell{}, simple)
+	want := "$env:A = 'it''s a value'\n"
+	if got != want {
+		t.Errorf("got:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestDialectDotenv(t *testing.T) {
+	simple := struct {
+		A string `env:"A"`
+	}{A: "line1\nline2 \"quoted\""}
+
+	got := marshalWith(t, env.DialectDotenv{}, simple)
+	want := `A="line1\nline2 \"quoted\""
+`
+	if got != want {
+		t.Errorf("got:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestDialectSystemd(t *testing.T) {
+	simple := struct {
+		A int    `env:"COUNT"`
+		B string `env:"NAME"`
+	}{A: 42, B: "hallo welt"}
+
+	got := marshalWith(t, env.DialectSystemd{}, simple)
+	want := "COUNT=42\nNAME=\"hallo welt\"\n"
+	if got != want {
+		t.Errorf("got:\n%q\nwant:\n%q", got, want)
+	}
+}
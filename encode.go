@@ -1,78 +1,164 @@
 // Package env contains the Marshal function for shell environment variables.
 // It looks for fields marked with the tag `env:"NAME"` and exports thier value as a shell variable NAME.
 // Supported types that can be tagged with `env:"NAME"`: primitive types, structs and pointers to those types.
+// Slices, arrays and maps are supported as well, rendered according to the
+// `sep=`, `kvsep=` and `explode` tag options.
+// A field tagged `env:"-"` is always skipped. Output is rendered through a
+// Dialect (DialectPOSIX by default); see MarshalWith to target other shells.
 package env
 
 import (
 	"bytes"
+	"encoding"
 	"fmt"
+	"io"
 	"reflect"
+	"regexp"
+	"sort"
 	"strings"
 )
 
+// Marshaler is implemented by types that can render themselves as a shell
+// variable value. It takes precedence over encoding.TextMarshaler and over
+// the built-in reflect.Kind handling.
+type Marshaler interface {
+	MarshalEnvValue() (string, error)
+}
+
 type encodeState struct {
-	bytes.Buffer
-	visited map[reflect.Value]bool
+	w        io.Writer
+	visited  map[reflect.Value]bool
+	dialect  Dialect
+	sortKeys bool
 }
 
 // Marshal looks for fields marked with the tag `env:"NAME"` and exports thier value as a shell variable NAME.
 // Supported types that can be tagged with `env:"NAME"`: primitive types, structs and pointers to those types.
+// Slices, arrays and maps are supported as well, rendered according to the `sep=`, `kvsep=` and `explode` tag options.
+// It renders output using DialectPOSIX; use MarshalWith for other shell dialects, or Encoder to stream
+// straight to an io.Writer instead of buffering the whole result.
 func Marshal(v interface{}) ([]byte, error) {
-	e := &encodeState{visited: make(map[reflect.Value]bool)}
-	err := e.marshal(v)
-	if err != nil {
+	return MarshalWith(v, DialectPOSIX{})
+}
+
+// MarshalWith behaves like Marshal, but renders each assignment using d
+// instead of the default DialectPOSIX.
+func MarshalWith(v interface{}, d Dialect) ([]byte, error) {
+	var buf bytes.Buffer
+	e := &encodeState{w: &buf, visited: make(map[reflect.Value]bool), dialect: d, sortKeys: true}
+	if err := e.marshal(v); err != nil {
 		return nil, err
 	}
-	return e.Bytes(), nil
+	return buf.Bytes(), nil
+}
+
+// Encoder writes shell environment variable assignments to an io.Writer as
+// they are produced, so large configs can be streamed straight to a file or
+// stdout without materializing the whole result in memory first.
+type Encoder struct {
+	w        io.Writer
+	dialect  Dialect
+	sortKeys bool
+}
+
+// NewEncoder returns a new Encoder that writes to w using DialectPOSIX.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w, dialect: DialectPOSIX{}, sortKeys: true}
+}
+
+// SetSortKeys controls whether map keys are sorted before being written.
+// Sorting is enabled by default for deterministic output; disabling it
+// avoids the sort at the cost of following Go's unspecified map iteration
+// order.
+func (enc *Encoder) SetSortKeys(sortKeys bool) {
+	enc.sortKeys = sortKeys
+}
+
+// SetDialect changes the Dialect used to render assignments, in place of the
+// default DialectPOSIX. It takes effect on the next call to Encode.
+func (enc *Encoder) SetDialect(d Dialect) {
+	enc.dialect = d
+}
+
+// Encode marshals v and writes the result to the Encoder's io.Writer. A
+// short or failing write aborts immediately with the underlying error.
+func (enc *Encoder) Encode(v interface{}) error {
+	e := &encodeState{w: enc.w, visited: make(map[reflect.Value]bool), dialect: enc.dialect, sortKeys: enc.sortKeys}
+	return e.marshal(v)
 }
 
 func (e *encodeState) marshal(v interface{}) (err error) {
-	return e.visitValue(reflect.ValueOf(v), "", false)
+	return e.visitValue(reflect.ValueOf(v), "", tagOptions(""))
 }
 
-func (e *encodeState) visitValue(v reflect.Value, tag string, omitEmpty bool) error {
+func (e *encodeState) visitValue(v reflect.Value, tag string, opts tagOptions) error {
 	if !v.IsValid() || e.visited[v] {
 		return nil
 	}
 
 	e.visited[v] = true
 
+	omitEmpty := opts.Contains("omitempty")
+	write := tag != "" && !(omitEmpty && isEmptyValue(v))
+
+	nilPtrOrInterface := (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil()
+	if write && !nilPtrOrInterface {
+		if handled, err := e.visitMarshaler(v, tag); handled || err != nil {
+			return err
+		}
+	}
+
 	switch v.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		if tag != "" && !(omitEmpty && isEmptyValue(v)) {
-			e.Buffer.WriteString(fmt.Sprintf("export %s='%v'\n", tag, v.Int()))
+		if write {
+			return e.writeAssignment(tag, fmt.Sprintf("%v", v.Int()))
 		}
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-		if tag != "" && !(omitEmpty && isEmptyValue(v)) {
-			e.Buffer.WriteString(fmt.Sprintf("export %s='%v'\n", tag, v.Uint()))
+		if write {
+			return e.writeAssignment(tag, fmt.Sprintf("%v", v.Uint()))
 		}
 	case reflect.Float32, reflect.Float64:
-		if tag != "" && !(omitEmpty && isEmptyValue(v)) {
-			e.Buffer.WriteString(fmt.Sprintf("export %s='%v'\n", tag, v.Float()))
+		if write {
+			return e.writeAssignment(tag, fmt.Sprintf("%v", v.Float()))
 		}
 	case reflect.String:
-		if tag != "" && !(omitEmpty && isEmptyValue(v)) {
-			e.Buffer.WriteString(fmt.Sprintf("export %s='%v'\n", tag, v.String()))
+		if write {
+			return e.writeAssignment(tag, v.String())
+		}
+	case reflect.Bool:
+		if write {
+			return e.writeAssignment(tag, formatBool(v.Bool(), opts))
 		}
 	case reflect.Ptr, reflect.Interface:
 		if !v.IsNil() {
-			return e.visitValue(v.Elem(), tag, false)
+			return e.visitValue(v.Elem(), tag, opts)
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			break
+		}
+		if write {
+			return e.visitSequence(v, tag, opts)
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			break
+		}
+		if write {
+			return e.visitMap(v, tag, opts)
 		}
 	case reflect.Struct:
-		for i := 0; i < v.Type().NumField(); i++ {
-			field := v.Field(i)
-			envTag := v.Type().Field(i).Tag.Get("env")
-			name, opts := parseTag(envTag)
-			if tag != "" && name == "" {
-				name = tag + "_" + v.Type().Field(i).Name
-			}
-			err := e.visitValue(field, name, opts.Contains("omitempty"))
-			if err != nil {
-				return fmt.Errorf("visiting %v: %w", v.Type().Field(i).Name, err)
+		fields, err := resolveStructFields(v, tag)
+		if err != nil {
+			return err
+		}
+		for _, f := range fields {
+			if err := e.visitValue(f.value, f.name, f.opts); err != nil {
+				return fmt.Errorf("visiting %v: %w", f.label, err)
 			}
 		}
 	default:
-		if tag != "" && !(omitEmpty && isEmptyValue(v)) {
+		if write {
 			return UnsupportedTypeError(v.Type().String())
 		}
 	}
@@ -80,6 +166,212 @@ func (e *encodeState) visitValue(v reflect.Value, tag string, omitEmpty bool) er
 	return nil
 }
 
+// formatBool renders a bool according to the tag's `bool=truthy/falsy`
+// option (e.g. `bool=1/0`), defaulting to Go's "true"/"false".
+func formatBool(b bool, opts tagOptions) string {
+	truthy, falsy := "true", "false"
+	if spec, ok := opts.Value("bool"); ok {
+		if idx := strings.Index(spec, "/"); idx != -1 {
+			truthy, falsy = spec[:idx], spec[idx+1:]
+		}
+	}
+	if b {
+		return truthy
+	}
+	return falsy
+}
+
+// identifierComponent matches a string that's safe to splice into a shell
+// variable name (e.g. appended after "TAG_" by an `explode` map key), so an
+// exploded assignment never ends up with a space, '=' or other
+// shell-significant character in its name.
+var identifierComponent = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// visitSequence renders a slice or array as a single separator-joined
+// variable (tag option `sep=`, default ","), or, with the `explode` tag
+// option, as one variable per element named NAME_0, NAME_1, ….
+func (e *encodeState) visitSequence(v reflect.Value, tag string, opts tagOptions) error {
+	if opts.Contains("explode") {
+		for i := 0; i < v.Len(); i++ {
+			name := fmt.Sprintf("%s_%d", tag, i)
+			if err := e.visitValue(v.Index(i), name, elemOptions(opts)); err != nil {
+				return fmt.Errorf("visiting %v[%d]: %w", tag, i, err)
+			}
+		}
+		return nil
+	}
+
+	sep, _ := opts.Value("sep")
+	if sep == "" {
+		sep = ","
+	}
+
+	elems := make([]string, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		s, err := stringifyElem(v.Index(i), opts)
+		if err != nil {
+			return fmt.Errorf("visiting %v[%d]: %w", tag, i, err)
+		}
+		elems[i] = s
+	}
+
+	return e.writeArray(tag, elems, sep)
+}
+
+// visitMap renders a map as a single variable of `k1=v1,k2=v2` pairs (tag
+// options `kvsep=` and `sep=`, defaulting to "=" and ","), or, with the
+// `explode` tag option, as one variable per entry named NAME_KEY. Keys are
+// sorted for deterministic output unless sorting was turned off with
+// Encoder.SetSortKeys(false). A KEY that isn't itself a valid shell
+// identifier component (letters, digits, underscore) is rejected rather
+// than spliced into an assignment it would break.
+func (e *encodeState) visitMap(v reflect.Value, tag string, opts tagOptions) error {
+	keys := v.MapKeys()
+	if e.sortKeys {
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+	}
+
+	if opts.Contains("explode") {
+		for _, k := range keys {
+			keyStr := fmt.Sprint(k.Interface())
+			if !identifierComponent.MatchString(keyStr) {
+				return fmt.Errorf("env: exploded map key %q is not a valid shell variable name component", keyStr)
+			}
+			name := tag + "_" + keyStr
+			if err := e.visitValue(v.MapIndex(k), name, elemOptions(opts)); err != nil {
+				return fmt.Errorf("visiting %v[%v]: %w", tag, k.Interface(), err)
+			}
+		}
+		return nil
+	}
+
+	kvsep, _ := opts.Value("kvsep")
+	if kvsep == "" {
+		kvsep = "="
+	}
+	sep, _ := opts.Value("sep")
+	if sep == "" {
+		sep = ","
+	}
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		s, err := stringifyElem(v.MapIndex(k), opts)
+		if err != nil {
+			return fmt.Errorf("visiting %v[%v]: %w", tag, k.Interface(), err)
+		}
+		pairs[i] = fmt.Sprintf("%v%s%s", k.Interface(), kvsep, s)
+	}
+
+	return e.writeAssignment(tag, strings.Join(pairs, sep))
+}
+
+// writeAssignment renders a single assignment through the active dialect.
+func (e *encodeState) writeAssignment(name, value string) error {
+	_, err := io.WriteString(e.w, e.dialect.FormatAssignment(name, value))
+	return err
+}
+
+// writeArray renders a slice/array through the active dialect's native
+// array syntax, if it has one (see arrayDialect); otherwise it falls back
+// to a single assignment with values joined by sep.
+func (e *encodeState) writeArray(name string, values []string, sep string) error {
+	if ad, ok := e.dialect.(arrayDialect); ok {
+		_, err := io.WriteString(e.w, ad.FormatArray(name, values))
+		return err
+	}
+	return e.writeAssignment(name, strings.Join(values, sep))
+}
+
+// stringifyElem renders a single slice/array/map element as a string for
+// joining into a separator-delimited value. opts is the container's tag
+// options, consulted for element-level formatting such as `bool=`.
+func stringifyElem(v reflect.Value, opts tagOptions) (string, error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return fmt.Sprintf("%v", v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return fmt.Sprintf("%v", v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return fmt.Sprintf("%v", v.Float()), nil
+	case reflect.Bool:
+		return formatBool(v.Bool(), opts), nil
+	default:
+		return "", UnsupportedTypeError(v.Type().String())
+	}
+}
+
+// elemOptions narrows a slice/map's tag options down to the subset that
+// should still apply when formatting one of its elements - currently just
+// `bool=`. Container-level options like `sep=`, `kvsep=` and `explode`
+// itself must not carry over to the element.
+func elemOptions(opts tagOptions) tagOptions {
+	if spec, ok := opts.Value("bool"); ok {
+		return tagOptions("bool=" + spec)
+	}
+	return tagOptions("")
+}
+
+// visitMarshaler checks whether v (or, if addressable, a pointer to v)
+// implements Marshaler or encoding.TextMarshaler, in that order of
+// precedence, and if so writes the resulting value for tag and reports
+// handled=true. Marshaler beats TextMarshaler beats the reflect-kind switch.
+func (e *encodeState) visitMarshaler(v reflect.Value, tag string) (handled bool, err error) {
+	if !v.CanInterface() {
+		return false, nil
+	}
+
+	iface := v.Interface()
+	if m, ok := iface.(Marshaler); ok {
+		value, err := m.MarshalEnvValue()
+		return e.writeMarshaled(tag, value, err)
+	}
+	if m, ok := iface.(encoding.TextMarshaler); ok {
+		value, err := textMarshalToString(m)
+		return e.writeMarshaled(tag, value, err)
+	}
+
+	if v.CanAddr() {
+		addr := v.Addr()
+		if m, ok := addr.Interface().(Marshaler); ok {
+			value, err := m.MarshalEnvValue()
+			return e.writeMarshaled(tag, value, err)
+		}
+		if m, ok := addr.Interface().(encoding.TextMarshaler); ok {
+			value, err := textMarshalToString(m)
+			return e.writeMarshaled(tag, value, err)
+		}
+	}
+
+	return false, nil
+}
+
+func textMarshalToString(m encoding.TextMarshaler) (string, error) {
+	b, err := m.MarshalText()
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (e *encodeState) writeMarshaled(tag, value string, err error) (bool, error) {
+	if err != nil {
+		return true, err
+	}
+	return true, e.writeAssignment(tag, value)
+}
+
 // UnsupportedTypeError is used when an unsupported type is marked to be marshalled.
 // Currenlty only primitive types and structs (and pointers to them) are supported.
 type UnsupportedTypeError string
@@ -103,21 +395,65 @@ func parseTag(tag string) (string, tagOptions) {
 	return tag, tagOptions("")
 }
 
+// tagOptionKeys lists the option keys that split the option string, in the
+// form they appear right after a separating comma. A separator value such
+// as `sep=,` may itself contain a comma, so splitting only happens at a
+// comma immediately followed by one of these keys, not at every comma.
+var tagOptionKeys = []string{"sep=", "kvsep=", "bool=", "prefix=", "explode", "omitempty"}
+
+// splitTagOptions splits a raw option string into its individual options.
+func splitTagOptions(o string) []string {
+	var parts []string
+	for o != "" {
+		boundary := -1
+		for i := 0; i < len(o); i++ {
+			if o[i] != ',' {
+				continue
+			}
+			rest := o[i+1:]
+			for _, key := range tagOptionKeys {
+				if strings.HasPrefix(rest, key) {
+					boundary = i
+					break
+				}
+			}
+			if boundary != -1 {
+				break
+			}
+		}
+		if boundary == -1 {
+			parts = append(parts, o)
+			break
+		}
+		parts = append(parts, o[:boundary])
+		o = o[boundary+1:]
+	}
+	return parts
+}
+
+// Value returns the value of a `key=value` tag option (e.g. "sep" in
+// `env:"PATH,sep=:"`) and whether it was present.
+func (o tagOptions) Value(optionName string) (string, bool) {
+	if len(o) == 0 {
+		return "", false
+	}
+	prefix := optionName + "="
+	for _, part := range splitTagOptions(string(o)) {
+		if strings.HasPrefix(part, prefix) {
+			return part[len(prefix):], true
+		}
+	}
+	return "", false
+}
+
 func (o tagOptions) Contains(optionName string) bool {
 	if len(o) == 0 {
 		return false
 	}
-	s := string(o)
-	for s != "" {
-		var next string
-		i := strings.Index(s, ",")
-		if i >= 0 {
-			s, next = s[:i], s[i+1:]
-		}
-		if s == optionName {
+	for _, part := range splitTagOptions(string(o)) {
+		if part == optionName {
 			return true
 		}
-		s = next
 	}
 	return false
 }
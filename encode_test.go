@@ -2,6 +2,7 @@ package env_test
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/jgf/env"
@@ -36,7 +37,7 @@ func TestSimpleStruct(t *testing.T) {
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
-	expected := "export MYVAR='hallo'\nexport B='4711'\n"
+	expected := "export MYVAR=hallo\nexport B=4711\n"
 	if string(data) != expected {
 		t.Errorf("marshalled data does not match expectation:\n%v\n%v", string(data), expected)
 	}
@@ -78,7 +79,7 @@ func TestSimplePointerStruct(t *testing.T) {
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
-	expected := "export MYVAR='hallo'\nexport B='4711'\n"
+	expected := "export MYVAR=hallo\nexport B=4711\n"
 	if string(data) != expected {
 		t.Errorf("marshalled data does not match expectation:\n%v\n%v", string(data), expected)
 	}
@@ -106,32 +107,30 @@ func TestMultiLayeredStruct(t *testing.T) {
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
-	expected := "export MYVAR='hallo'\nexport S1_C='welt'\nexport B='4711'\n"
+	expected := "export MYVAR=hallo\nexport S1_C=welt\nexport B=4711\n"
 	if string(data) != expected {
 		t.Errorf("marshalled data does not match expectation:\n%v\n%v", string(data), expected)
 	}
 }
 
-func TestErrorUnsupportedTypeSlice(t *testing.T) {
+func TestSliceWithSeparator(t *testing.T) {
 	simple := struct {
-		A []string `env:"MYSLICE"`
+		A []string `env:"PATH,sep=:"`
 	}{
 		A: []string{"hallo", "welt"},
 	}
 
 	data, err := env.Marshal(simple)
-	if err == nil {
-		t.Errorf("expected error did not occur")
-	} else if !errors.Is(err, env.UnsupportedTypeError("[]string")) {
+	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
-
-	if data != nil {
-		t.Errorf("expected empty marshalled data, got:\n%v", string(data))
+	expected := "export PATH=hallo:welt\n"
+	if string(data) != expected {
+		t.Errorf("marshalled data does not match expectation:\n%v\n%v", string(data), expected)
 	}
 }
 
-func TestErrorUnsupportedTypeArray(t *testing.T) {
+func TestArrayDefaultSeparator(t *testing.T) {
 	simple := struct {
 		A [2]string `env:"MYARR"`
 	}{
@@ -139,18 +138,33 @@ func TestErrorUnsupportedTypeArray(t *testing.T) {
 	}
 
 	data, err := env.Marshal(simple)
-	if err == nil {
-		t.Errorf("expected error did not occur")
-	} else if !errors.Is(err, env.UnsupportedTypeError("[2]string")) {
+	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
+	expected := "export MYARR='hallo,welt'\n"
+	if string(data) != expected {
+		t.Errorf("marshalled data does not match expectation:\n%v\n%v", string(data), expected)
+	}
+}
 
-	if data != nil {
-		t.Errorf("expected empty marshalled data, got:\n%v", string(data))
+func TestSliceExplode(t *testing.T) {
+	simple := struct {
+		A []string `env:"TAGS,explode"`
+	}{
+		A: []string{"hallo", "welt"},
+	}
+
+	data, err := env.Marshal(simple)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expected := "export TAGS_0=hallo\nexport TAGS_1=welt\n"
+	if string(data) != expected {
+		t.Errorf("marshalled data does not match expectation:\n%v\n%v", string(data), expected)
 	}
 }
 
-func TestErrorUnsupportedTypeMap(t *testing.T) {
+func TestMapDefaultSeparators(t *testing.T) {
 	simple := struct {
 		A map[string]int `env:"MYMAP"`
 	}{
@@ -158,14 +172,282 @@ func TestErrorUnsupportedTypeMap(t *testing.T) {
 	}
 
 	data, err := env.Marshal(simple)
-	if err == nil {
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expected := "export MYMAP='hallo=42,welt=4711'\n"
+	if string(data) != expected {
+		t.Errorf("marshalled data does not match expectation:\n%v\n%v", string(data), expected)
+	}
+}
+
+func TestMapWithSeparators(t *testing.T) {
+	simple := struct {
+		A map[string]string `env:"LABELS,kvsep==,sep=,"`
+	}{
+		A: map[string]string{"k1": "v1", "k2": "v2"},
+	}
+
+	data, err := env.Marshal(simple)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expected := "export LABELS='k1=v1,k2=v2'\n"
+	if string(data) != expected {
+		t.Errorf("marshalled data does not match expectation:\n%v\n%v", string(data), expected)
+	}
+}
+
+func TestMapExplode(t *testing.T) {
+	simple := struct {
+		A map[string]int `env:"MYMAP,explode"`
+	}{
+		A: map[string]int{"hallo": 42},
+	}
+
+	data, err := env.Marshal(simple)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expected := "export MYMAP_hallo=42\n"
+	if string(data) != expected {
+		t.Errorf("marshalled data does not match expectation:\n%v\n%v", string(data), expected)
+	}
+}
+
+func TestMapExplodeRejectsUnsafeKey(t *testing.T) {
+	simple := struct {
+		A map[string]string `env:"MYMAP,explode"`
+	}{
+		A: map[string]string{"hello world": "x"},
+	}
+
+	if _, err := env.Marshal(simple); err == nil {
 		t.Errorf("expected error did not occur")
-	} else if !errors.Is(err, env.UnsupportedTypeError("map[string]int")) {
+	}
+}
+
+func TestBoolDefaultFormat(t *testing.T) {
+	simple := struct {
+		A bool `env:"FLAG"`
+	}{A: true}
+
+	data, err := env.Marshal(simple)
+	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
+	expected := "export FLAG=true\n"
+	if string(data) != expected {
+		t.Errorf("marshalled data does not match expectation:\n%v\n%v", string(data), expected)
+	}
+}
 
-	if data != nil {
-		t.Errorf("expected empty marshalled data, got:\n%v", string(data))
+func TestBoolCustomFormat(t *testing.T) {
+	simple := struct {
+		A bool `env:"FLAG,bool=1/0"`
+	}{A: true}
+
+	data, err := env.Marshal(simple)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expected := "export FLAG=1\n"
+	if string(data) != expected {
+		t.Errorf("marshalled data does not match expectation:\n%v\n%v", string(data), expected)
+	}
+}
+
+func TestSliceExplodeBoolFormat(t *testing.T) {
+	simple := struct {
+		A []bool `env:"FLAGS,explode,bool=1/0"`
+	}{
+		A: []bool{true, false},
+	}
+
+	data, err := env.Marshal(simple)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expected := "export FLAGS_0=1\nexport FLAGS_1=0\n"
+	if string(data) != expected {
+		t.Errorf("marshalled data does not match expectation:\n%v\n%v", string(data), expected)
+	}
+}
+
+func TestSliceWithSeparatorBoolFormat(t *testing.T) {
+	simple := struct {
+		A []bool `env:"FLAGS,bool=1/0"`
+	}{
+		A: []bool{true, false},
+	}
+
+	data, err := env.Marshal(simple)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expected := "export FLAGS='1,0'\n"
+	if string(data) != expected {
+		t.Errorf("marshalled data does not match expectation:\n%v\n%v", string(data), expected)
+	}
+}
+
+type duration int
+
+func (d duration) MarshalEnvValue() (string, error) {
+	return fmt.Sprintf("%dms", int(d)), nil
+}
+
+func TestMarshalerTakesPrecedence(t *testing.T) {
+	simple := struct {
+		D duration `env:"D"`
+	}{D: 1500}
+
+	data, err := env.Marshal(simple)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expected := "export D=1500ms\n"
+	if string(data) != expected {
+		t.Errorf("marshalled data does not match expectation:\n%v\n%v", string(data), expected)
+	}
+}
+
+type hexColor uint32
+
+func (h hexColor) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("#%06x", uint32(h))), nil
+}
+
+func TestMarshalerNilPointerSkipped(t *testing.T) {
+	simple := struct {
+		T *duration `env:"T"`
+	}{T: nil}
+
+	data, err := env.Marshal(simple)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expected := ""
+	if string(data) != expected {
+		t.Errorf("marshalled data does not match expectation:\n%v\n%v", string(data), expected)
+	}
+}
+
+func TestTextMarshalerFallback(t *testing.T) {
+	simple := struct {
+		C hexColor `env:"COLOR"`
+	}{C: 0xff00aa}
+
+	data, err := env.Marshal(simple)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expected := "export COLOR='#ff00aa'\n"
+	if string(data) != expected {
+		t.Errorf("marshalled data does not match expectation:\n%v\n%v", string(data), expected)
+	}
+}
+
+func TestSkipTag(t *testing.T) {
+	simple := struct {
+		A string `env:"MYVAR"`
+		B string `env:"-"`
+	}{
+		A: "hallo",
+		B: "welt",
+	}
+
+	data, err := env.Marshal(simple)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expected := "export MYVAR=hallo\n"
+	if string(data) != expected {
+		t.Errorf("marshalled data does not match expectation:\n%v\n%v", string(data), expected)
+	}
+}
+
+type embeddedBase struct {
+	Host string `env:"HOST"`
+}
+
+func TestEmbeddedStructPromotion(t *testing.T) {
+	simple := struct {
+		embeddedBase
+		Port int `env:"PORT"`
+	}{
+		embeddedBase: embeddedBase{Host: "localhost"},
+		Port:         8080,
+	}
+
+	data, err := env.Marshal(simple)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expected := "export HOST=localhost\nexport PORT=8080\n"
+	if string(data) != expected {
+		t.Errorf("marshalled data does not match expectation:\n%v\n%v", string(data), expected)
+	}
+}
+
+type dominantOuter struct {
+	embeddedBase
+	Host string `env:"HOST"`
+}
+
+func TestEmbeddedStructDominantFieldWins(t *testing.T) {
+	simple := dominantOuter{
+		embeddedBase: embeddedBase{Host: "inner"},
+		Host:         "outer",
+	}
+
+	data, err := env.Marshal(simple)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expected := "export HOST=outer\n"
+	if string(data) != expected {
+		t.Errorf("marshalled data does not match expectation:\n%v\n%v", string(data), expected)
+	}
+}
+
+type ambiguousA struct {
+	Host string `env:"HOST"`
+}
+
+type ambiguousB struct {
+	Host string `env:"HOST"`
+}
+
+func TestEmbeddedStructAmbiguousCollisionError(t *testing.T) {
+	simple := struct {
+		ambiguousA
+		ambiguousB
+	}{
+		ambiguousA: ambiguousA{Host: "a"},
+		ambiguousB: ambiguousB{Host: "b"},
+	}
+
+	_, err := env.Marshal(simple)
+	if err == nil {
+		t.Errorf("expected error did not occur")
+	}
+}
+
+func TestStructFieldPrefixOption(t *testing.T) {
+	simple := struct {
+		S1 sA `env:",prefix=PRE_"`
+	}{
+		S1: sA{A: "hallo", C: "welt"},
+	}
+
+	data, err := env.Marshal(simple)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expected := "export MYVAR=hallo\nexport PRE_C=welt\n"
+	if string(data) != expected {
+		t.Errorf("marshalled data does not match expectation:\n%v\n%v", string(data), expected)
 	}
 }
 
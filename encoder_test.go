@@ -0,0 +1,79 @@
+package env_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/jgf/env"
+)
+
+func TestEncoderWritesToWriter(t *testing.T) {
+	simple := struct {
+		A string `env:"MYVAR"`
+		B int    `env:"B"`
+	}{A: "hallo", B: 4711}
+
+	var buf bytes.Buffer
+	if err := env.NewEncoder(&buf).Encode(simple); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	expected := "export MYVAR=hallo\nexport B=4711\n"
+	if buf.String() != expected {
+		t.Errorf("encoded data does not match expectation:\n%v\n%v", buf.String(), expected)
+	}
+}
+
+func TestEncoderSetSortKeysFalse(t *testing.T) {
+	simple := struct {
+		A map[string]int `env:"MYMAP"`
+	}{A: map[string]int{"a": 1}}
+
+	var buf bytes.Buffer
+	enc := env.NewEncoder(&buf)
+	enc.SetSortKeys(false)
+	if err := enc.Encode(simple); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	expected := "export MYMAP=a=1\n"
+	if buf.String() != expected {
+		t.Errorf("encoded data does not match expectation:\n%v\n%v", buf.String(), expected)
+	}
+}
+
+func TestEncoderSetDialect(t *testing.T) {
+	simple := struct {
+		A string `env:"MYVAR"`
+	}{A: "hallo"}
+
+	var buf bytes.Buffer
+	enc := env.NewEncoder(&buf)
+	enc.SetDialect(env.DialectFish{})
+	if err := enc.Encode(simple); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	expected := "set -x MYVAR hallo\n"
+	if buf.String() != expected {
+		t.Errorf("encoded data does not match expectation:\n%v\n%v", buf.String(), expected)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("short write")
+}
+
+func TestEncoderPropagatesWriteError(t *testing.T) {
+	simple := struct {
+		A string `env:"MYVAR"`
+	}{A: "hallo"}
+
+	err := env.NewEncoder(failingWriter{}).Encode(simple)
+	if err == nil {
+		t.Errorf("expected error did not occur")
+	}
+}
@@ -0,0 +1,18 @@
+package env
+
+import "regexp"
+
+// shellSafe matches the characters that may appear unquoted in a POSIX
+// shell word without risking word-splitting, globbing, or injection.
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_./:@%+=-]+$`)
+
+// shellquote renders s as a POSIX-safe shell word. Empty strings, and
+// strings containing any character outside shellSafe, are wrapped in
+// single quotes with embedded single quotes escaped as '\''. Safe strings
+// are emitted bare.
+func shellquote(s string) string {
+	if s != "" && shellSafe.MatchString(s) {
+		return s
+	}
+	return posixQuote(s)
+}
@@ -0,0 +1,50 @@
+package env_test
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/jgf/env"
+)
+
+// FuzzMarshalShellRoundTrip marshals a struct with a random string, executes
+// the result under a real shell, and asserts the value round-trips exactly
+// as written, guarding against shell-injection in the POSIX quoting.
+func FuzzMarshalShellRoundTrip(f *testing.F) {
+	f.Add("hello")
+	f.Add("hello world")
+	f.Add("it's a test")
+	f.Add(`foo'; rm -rf ~;'`)
+	f.Add("$(whoami) and `whoami`")
+	f.Add("line1\nline2")
+	f.Add("")
+
+	f.Fuzz(func(t *testing.T, s string) {
+		if strings.ContainsRune(s, 0) {
+			t.Skip("NUL bytes cannot be represented in an environment variable")
+		}
+
+		simple := struct {
+			V string `env:"FUZZVAR"`
+		}{V: s}
+
+		data, err := env.Marshal(simple)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		script := string(data) + `printf '%s' "$FUZZVAR"`
+		cmd := exec.Command("sh", "-c", script)
+		var out bytes.Buffer
+		cmd.Stdout = &out
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("shell execution of %q failed: %v", script, err)
+		}
+
+		if out.String() != s {
+			t.Errorf("round-trip mismatch: got %q, want %q", out.String(), s)
+		}
+	})
+}
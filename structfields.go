@@ -0,0 +1,147 @@
+package env
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// fieldSlot is a field of a struct (or one promoted from an embedded
+// anonymous struct) ready to be visited, with its final variable name
+// already resolved.
+type fieldSlot struct {
+	name  string // resolved env variable name/prefix, or "" if untagged with no ancestor prefix
+	label string // the Go field name, used for error messages
+	value reflect.Value
+	opts  tagOptions
+	depth int // 0 for fields declared directly on the struct, +1 per level of anonymous promotion
+}
+
+// resolveStructFields returns the fields of v to visit under tag, honoring
+// `env:"-"` skips, the `prefix=` tag option, and promotion of anonymous
+// (embedded) fields that carry no env tag of their own - fields of such a
+// struct are flattened into v's own namespace, exactly as encoding/json
+// promotes anonymous fields without a json tag.
+//
+// When promotion causes two fields to resolve to the same variable name,
+// the shallower one wins; a tie at the same depth is a marshal-time error.
+func resolveStructFields(v reflect.Value, tag string) ([]fieldSlot, error) {
+	slots, err := collectStructFields(v, tag, 0)
+	if err != nil {
+		return nil, err
+	}
+	return resolveDominantFields(slots)
+}
+
+// joinName joins a parent tag/prefix with a field name. A prefix= value is
+// expected to already carry its own trailing separator (e.g. "PRE_"), so it
+// is not joined again with "_"; the implicit TAG_FieldName chaining still
+// adds one.
+func joinName(tag, fieldName string) string {
+	if strings.HasSuffix(tag, "_") {
+		return tag + fieldName
+	}
+	return tag + "_" + fieldName
+}
+
+func collectStructFields(v reflect.Value, tag string, depth int) ([]fieldSlot, error) {
+	t := v.Type()
+	var slots []fieldSlot
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		envTag := field.Tag.Get("env")
+		if envTag == "-" {
+			continue
+		}
+		name, opts := parseTag(envTag)
+
+		if field.Anonymous && name == "" {
+			if nested, ok, err := collectPromoted(v.Field(i), tag, depth, opts); err != nil {
+				return nil, err
+			} else if ok {
+				slots = append(slots, nested...)
+				continue
+			}
+		}
+
+		childName := name
+		if childName == "" {
+			if prefix, ok := opts.Value("prefix"); ok {
+				childName = prefix
+			} else if tag != "" {
+				childName = joinName(tag, field.Name)
+			}
+		}
+
+		slots = append(slots, fieldSlot{name: childName, label: field.Name, value: v.Field(i), opts: opts, depth: depth})
+	}
+
+	return slots, nil
+}
+
+// collectPromoted expands an anonymous struct (or pointer-to-struct) field
+// into its own promoted fields, reusing the prefix= option (if any) as the
+// namespace those promoted fields join under, instead of the outer tag.
+func collectPromoted(fv reflect.Value, tag string, depth int, opts tagOptions) ([]fieldSlot, bool, error) {
+	for fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return nil, true, nil
+		}
+		fv = fv.Elem()
+	}
+	if fv.Kind() != reflect.Struct {
+		return nil, false, nil
+	}
+
+	childTag := tag
+	if prefix, ok := opts.Value("prefix"); ok {
+		childTag = prefix
+	}
+
+	nested, err := collectStructFields(fv, childTag, depth+1)
+	return nested, true, err
+}
+
+// resolveDominantFields applies the dominant-field rule to slots with a
+// resolved name, leaving untagged passthrough slots (name == "") untouched
+// so their own descendants still get a chance to be visited.
+func resolveDominantFields(slots []fieldSlot) ([]fieldSlot, error) {
+	type best struct {
+		slot  fieldSlot
+		count int
+	}
+
+	bestByName := make(map[string]best)
+	var order []string
+	var passthrough []fieldSlot
+
+	for _, s := range slots {
+		if s.name == "" {
+			passthrough = append(passthrough, s)
+			continue
+		}
+		cur, ok := bestByName[s.name]
+		switch {
+		case !ok:
+			bestByName[s.name] = best{slot: s, count: 1}
+			order = append(order, s.name)
+		case s.depth < cur.slot.depth:
+			bestByName[s.name] = best{slot: s, count: 1}
+		case s.depth == cur.slot.depth:
+			bestByName[s.name] = best{slot: cur.slot, count: cur.count + 1}
+		}
+	}
+
+	resolved := make([]fieldSlot, 0, len(order)+len(passthrough))
+	for _, name := range order {
+		b := bestByName[name]
+		if b.count > 1 {
+			return nil, fmt.Errorf("env: ambiguous variable name %q: %d fields at the same depth", name, b.count)
+		}
+		resolved = append(resolved, b.slot)
+	}
+	resolved = append(resolved, passthrough...)
+
+	return resolved, nil
+}